@@ -0,0 +1,221 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	// bundleSchemaVersion identifies the shape of a bundle's manifest so
+	// -verify can evolve independently of the rest of the output formats.
+	bundleSchemaVersion = "1"
+
+	bundleManifestEntry = "manifest.json"
+	bundleResultsEntry  = "results.jsonl"
+	bundleSigEntry      = "bundle.sig"
+
+	signKeyEnvVar   = "AMASS_SIGN_KEY"
+	verifyKeyEnvVar = "AMASS_VERIFY_KEY"
+)
+
+// bundleManifest records enough of a completed scan's configuration that
+// a client receiving the bundle can confirm what wordlist/config
+// produced the results, and when.
+type bundleManifest struct {
+	Schema       string    `json:"schema"`
+	AmassVersion string    `json:"amass_version"`
+	Domains      []string  `json:"domains"`
+	WordlistHash string    `json:"wordlist_sha256,omitempty"`
+	BruteForcing bool      `json:"brute_forcing"`
+	Recursive    bool      `json:"recursive"`
+	Created      time.Time `json:"created"`
+	Results      int       `json:"results"`
+}
+
+// writeBundle packages a completed scan's results as a zip containing a
+// config manifest and a results.jsonl file, optionally adding a detached
+// Ed25519 signature over the pair so a bundle can later be verified.
+func writeBundle(path string, manifest *bundleManifest, records []*resultRecord, signKey ed25519.PrivateKey) error {
+	var resultsBuf bytes.Buffer
+
+	enc := json.NewEncoder(&resultsBuf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	manifest.Results = len(records)
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	if err := writeZipEntry(zw, bundleManifestEntry, manifestBytes); err != nil {
+		return err
+	}
+	if err := writeZipEntry(zw, bundleResultsEntry, resultsBuf.Bytes()); err != nil {
+		return err
+	}
+
+	if signKey != nil {
+		sig := ed25519.Sign(signKey, bundleSignedBytes(manifestBytes, resultsBuf.Bytes()))
+		encoded := []byte(base64.StdEncoding.EncodeToString(sig))
+		if err := writeZipEntry(zw, bundleSigEntry, encoded); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// bundleSignedBytes is the canonical byte sequence signed and verified
+// for a bundle: the manifest followed by the results file.
+func bundleSignedBytes(manifest, results []byte) []byte {
+	signed := make([]byte, 0, len(manifest)+len(results))
+	signed = append(signed, manifest...)
+	signed = append(signed, results...)
+	return signed
+}
+
+// verifyBundle checks a bundle's detached signature against pub (when
+// pub is non-nil) and returns its manifest and results.
+func verifyBundle(path string, pub ed25519.PublicKey) (*bundleManifest, []*resultRecord, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	files := make(map[string][]byte)
+	for _, zf := range r.File {
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[zf.Name] = data
+	}
+
+	manifestBytes, found := files[bundleManifestEntry]
+	if !found {
+		return nil, nil, fmt.Errorf("bundle missing %s", bundleManifestEntry)
+	}
+	resultsBytes, found := files[bundleResultsEntry]
+	if !found {
+		return nil, nil, fmt.Errorf("bundle missing %s", bundleResultsEntry)
+	}
+
+	if pub != nil {
+		sigB64, found := files[bundleSigEntry]
+		if !found {
+			return nil, nil, fmt.Errorf("bundle has no signature to verify")
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(string(sigB64))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ed25519.Verify(pub, bundleSignedBytes(manifestBytes, resultsBytes), sig) {
+			return nil, nil, fmt.Errorf("bundle signature verification failed")
+		}
+	}
+
+	var manifest bundleManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, nil, err
+	}
+
+	var records []*resultRecord
+	scanner := bufio.NewScanner(bytes.NewReader(resultsBytes))
+	for scanner.Scan() {
+		var rec resultRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, &rec)
+	}
+
+	return &manifest, records, scanner.Err()
+}
+
+// printManifest prints a bundle's manifest ahead of its results, as
+// -verify is documented to do.
+func printManifest(m *bundleManifest) {
+	fmt.Printf("Schema: %s\n", m.Schema)
+	fmt.Printf("Amass version: %s\n", m.AmassVersion)
+	fmt.Printf("Created: %s\n", m.Created.Format(time.RFC3339))
+	fmt.Printf("Domains: %v\n", m.Domains)
+	fmt.Printf("Brute forcing: %v\n", m.BruteForcing)
+	fmt.Printf("Recursive: %v\n", m.Recursive)
+	fmt.Printf("Wordlist SHA-256: %s\n", m.WordlistHash)
+	fmt.Printf("Results: %d\n\n", m.Results)
+}
+
+// loadSignKey reads an Ed25519 private key from path, or from the
+// AMASS_SIGN_KEY environment variable (base64) when path is empty.
+func loadSignKey(path string) (ed25519.PrivateKey, error) {
+	data, err := readKeyMaterial(path, signKeyEnvVar)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("sign key must be %d bytes", ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(data), nil
+}
+
+// loadVerifyKey reads an Ed25519 public key from path, or from the
+// AMASS_VERIFY_KEY environment variable (base64) when path is empty.
+func loadVerifyKey(path string) (ed25519.PublicKey, error) {
+	data, err := readKeyMaterial(path, verifyKeyEnvVar)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("verify key must be %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+func readKeyMaterial(path, envVar string) ([]byte, error) {
+	if path != "" {
+		return ioutil.ReadFile(path)
+	}
+	if encoded := os.Getenv(envVar); encoded != "" {
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+	return nil, nil
+}
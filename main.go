@@ -5,10 +5,16 @@ package main
 
 import (
 	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,6 +29,18 @@ import (
 
 const (
 	defaultWordlistURL = "https://raw.githubusercontent.com/caffix/amass/master/wordlists/namelist.txt"
+
+	// amassVersion is recorded in journal headers so a replayed or
+	// resumed scan can be traced back to the release that produced it.
+	amassVersion = "dev"
+
+	// outputSchemaVersion identifies the shape of the records written by
+	// the json/jsonl/csv encoders so consumers can adapt across releases.
+	outputSchemaVersion = "1"
+
+	// journalSchemaVersion identifies the shape of the records written
+	// to a -journal file so -replay/-resume can evolve independently.
+	journalSchemaVersion = "1"
 )
 
 var AsciiArt string = `
@@ -46,19 +64,31 @@ var AsciiArt string = `
 `
 
 type outputParams struct {
-	Verbose  bool
-	Sources  bool
-	PrintIPs bool
-	FileOut  string
-	Results  chan *amass.AmassRequest
-	Finish   chan struct{}
-	Done     chan struct{}
+	Verbose    bool
+	Sources    bool
+	PrintIPs   bool
+	NoStdout   bool
+	FileOut    string
+	Format     string
+	JournalOut string
+	BundleOut  string
+	SignKey    ed25519.PrivateKey
+	Domains    []string
+	Wordlist   []string
+	BruteForce bool
+	Recursive  bool
+	Frequency  time.Duration
+	Seed       int64
+	Results    chan *amass.AmassRequest
+	Finish     chan struct{}
+	Done       chan struct{}
 }
 
 func main() {
 	var freq int64
-	var wordlist, file, domainsfile string
-	var verbose, extra, ip, brute, recursive, whois, list, help bool
+	var wordlist, file, format, journal, replay, resume, serve, metrics, domainsfile string
+	var bundle, signKeyPath, verifyBundlePath, verifyKeyPath string
+	var verbose, extra, ip, brute, recursive, whois, list, help, tui bool
 
 	flag.BoolVar(&help, "h", false, "Show the program usage message")
 	flag.StringVar(&domainsfile, "domains", "", "Path to the domains file")
@@ -72,18 +102,61 @@ func main() {
 	flag.Int64Var(&freq, "freq", 0, "Sets the number of max DNS queries per minute")
 	flag.StringVar(&wordlist, "w", "", "Path to a different wordlist file")
 	flag.StringVar(&file, "o", "", "Path to the output file")
+	flag.StringVar(&format, "of", "text", "Output format: text, json, jsonl or csv")
+	flag.StringVar(&journal, "journal", "", "Path to record a replayable journal of the enumeration")
+	flag.StringVar(&replay, "replay", "", "Path to a journal to replay instead of performing a new enumeration")
+	flag.StringVar(&resume, "resume", "", "Path to a journal of names already discovered by an interrupted scan")
+	flag.StringVar(&serve, "serve", "", "Address to run a long-lived HTTP/JSON scan API on (e.g. :8080)")
+	flag.BoolVar(&tui, "tui", false, "Render a live terminal dashboard of scan progress")
+	flag.StringVar(&metrics, "metrics", "", "Address to expose scan progress as Prometheus metrics on (e.g. :9090)")
+	flag.StringVar(&bundle, "bundle", "", "Path to package the results as a verifiable bundle (.zip), signed when a sign key is given")
+	flag.StringVar(&signKeyPath, "sign-key", "", "Path to an Ed25519 private key to sign the bundle (or set AMASS_SIGN_KEY)")
+	flag.StringVar(&verifyBundlePath, "verify", "", "Path to a bundle to verify and print instead of performing a new enumeration")
+	flag.StringVar(&verifyKeyPath, "verify-key", "", "Path to an Ed25519 public key to verify the bundle (or set AMASS_VERIFY_KEY)")
 	flag.Parse()
 
 	if extra {
 		verbose = true
 	}
 
+	if serve != "" {
+		if err := serveAPI(serve, freqToDuration(freq)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running the API server: %v\n", err)
+		}
+		return
+	}
+
+	if verifyBundlePath != "" {
+		if verifyKeyPath == "" && os.Getenv(verifyKeyEnvVar) == "" {
+			fmt.Fprintf(os.Stderr, "Error: -verify requires -verify-key or %s to be set\n", verifyKeyEnvVar)
+			return
+		}
+
+		pub, err := loadVerifyKey(verifyKeyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading the verification key: %v\n", err)
+			return
+		}
+
+		manifest, records, err := verifyBundle(verifyBundlePath, pub)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error verifying the bundle: %v\n", err)
+			return
+		}
+
+		printManifest(manifest)
+		for _, rec := range records {
+			fmt.Println(rec.Name)
+		}
+		return
+	}
+
 	domains := flag.Args()
 	if domainsfile != "" {
 		domains = append(domains, getFile(domainsfile)...)
 	}
 
-	if help || len(domains) == 0 {
+	if help || (len(domains) == 0 && replay == "") {
 		fmt.Println(AsciiArt)
 		fmt.Printf("Usage: %s [options] domain domain2 domain3... (e.g. example.com)\n", path.Base(os.Args[0]))
 		fmt.Printf("Or just send a file in the options with -domains\n")
@@ -105,32 +178,89 @@ func main() {
 	}
 
 	// Seed the pseudo-random number generator
-	rand.Seed(time.Now().UTC().UnixNano())
+	seed := time.Now().UTC().UnixNano()
+	rand.Seed(seed)
+
+	var signKey ed25519.PrivateKey
+	if bundle != "" {
+		var err error
+		signKey, err = loadSignKey(signKeyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading the signing key: %v\n", err)
+			return
+		}
+	}
 
 	finish := make(chan struct{})
 	done := make(chan struct{})
 	results := make(chan *amass.AmassRequest, 100)
+	words := getWordlist(wordlist)
 
 	go manageOutput(&outputParams{
-		Verbose:  verbose,
-		Sources:  extra,
-		PrintIPs: ip,
-		FileOut:  file,
-		Results:  results,
-		Finish:   finish,
-		Done:     done,
+		Verbose:    verbose,
+		Sources:    extra,
+		PrintIPs:   ip,
+		NoStdout:   tui,
+		FileOut:    file,
+		Format:     format,
+		JournalOut: journal,
+		BundleOut:  bundle,
+		SignKey:    signKey,
+		Domains:    domains,
+		Wordlist:   words,
+		BruteForce: brute,
+		Recursive:  recursive,
+		Frequency:  freqToDuration(freq),
+		Seed:       seed,
+		Results:    results,
+		Finish:     finish,
+		Done:       done,
 	})
 	// Execute the signal handler
 	go catchSignals(finish, done)
-	// Begin the enumeration process
-	amass.StartAmass(&amass.AmassConfig{
-		Domains:      domains,
-		Wordlist:     getWordlist(wordlist),
-		BruteForcing: brute,
-		Recursive:    recursive,
-		Frequency:    freqToDuration(freq),
-		Output:       results,
-	})
+
+	if replay != "" {
+		// Read a previously recorded journal back through the output
+		// pipeline instead of touching the network
+		if err := replayJournal(replay, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error replaying the journal: %v\n", err)
+		}
+	} else {
+		var resumeNames []string
+		if resume != "" {
+			var err error
+			resumeNames, err = loadJournalNames(resume)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading the resume journal: %v\n", err)
+			}
+		}
+
+		var progress chan *amass.AmassStats
+		if tui || metrics != "" {
+			progress = make(chan *amass.AmassStats, 10)
+		}
+		if tui {
+			go runTUI(progress)
+		} else if metrics != "" {
+			go func() {
+				if err := serveMetrics(metrics, progress); err != nil {
+					fmt.Fprintf(os.Stderr, "Error running the metrics server: %v\n", err)
+				}
+			}()
+		}
+
+		// Begin the enumeration process
+		amass.StartAmass(&amass.AmassConfig{
+			Domains:      domains,
+			Wordlist:     words,
+			BruteForcing: brute,
+			Recursive:    recursive,
+			Frequency:    freqToDuration(freq),
+			ResumeNames:  resumeNames,
+			Progress:     progress,
+			Output:       results,
+		})
+	}
 	// Signal for output to finish
 	finish <- struct{}{}
 	<-done
@@ -143,10 +273,52 @@ type asnData struct {
 
 func manageOutput(params *outputParams) {
 	var total int
-	var allLines string
 
 	tags := make(map[string]int)
 	asns := make(map[int]*asnData)
+
+	encoder, err := newResultEncoder(params.Format, params.Sources, params.PrintIPs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up the output format: %v\n", err)
+		close(params.Done)
+		return
+	}
+
+	// -tui owns the terminal's stdout for its live dashboard, so results
+	// are only written there when nothing else is redrawing the screen
+	out := io.Writer(os.Stdout)
+	if params.NoStdout {
+		out = io.Discard
+	}
+	if params.FileOut != "" {
+		f, err := os.Create(params.FileOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating the output file: %v\n", err)
+		} else {
+			defer f.Close()
+			if params.NoStdout {
+				out = f
+			} else {
+				out = io.MultiWriter(os.Stdout, f)
+			}
+		}
+	}
+
+	var journal *os.File
+	if params.JournalOut != "" {
+		var err error
+		journal, err = os.Create(params.JournalOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating the journal file: %v\n", err)
+		} else {
+			defer journal.Close()
+			writeJournalHeader(journal, params.Domains, params.Wordlist, params.BruteForce, params.Recursive, params.Frequency, params.Seed)
+		}
+	}
+
+	var records []*resultRecord
+
+	encoder.WriteHeader(out)
 loop:
 	for {
 		select {
@@ -154,35 +326,209 @@ loop:
 			total++
 			updateData(result, tags, asns)
 
-			var line string
-			if params.Sources {
-				line += fmt.Sprintf("%-14s", "["+result.Source+"] ")
+			// Encode and stream the result right away so long-running
+			// scans can be tailed through the chosen output format
+			if err := encoder.WriteResult(out, result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
 			}
-			if params.PrintIPs {
-				line += fmt.Sprintf("%s\n", result.Name+","+result.Address)
-			} else {
-				line += fmt.Sprintf("%s\n", result.Name)
+			if journal != nil {
+				writeJournalResult(journal, result)
+			}
+			if params.BundleOut != "" {
+				records = append(records, newResultRecord(result))
 			}
-
-			// Add line to the others and print it out
-			allLines += line
-			fmt.Print(line)
 		case <-params.Finish:
 			break loop
 		}
 	}
-	// Check to print the summary information
+	encoder.WriteFooter(out)
+
+	// Check to print the summary information. When -tui owns stdout for
+	// its live redraw loop, send the summary where the results went
+	// instead of racing the next dashboard frame on the terminal.
 	if params.Verbose {
-		printSummary(total, tags, asns)
+		printSummary(out, total, tags, asns)
 	}
-	// Check to output the results to a file
-	if params.FileOut != "" {
-		ioutil.WriteFile(params.FileOut, []byte(allLines), 0644)
+	// Check to package a signed, verifiable bundle of the results
+	if params.BundleOut != "" {
+		manifest := &bundleManifest{
+			Schema:       bundleSchemaVersion,
+			AmassVersion: amassVersion,
+			Domains:      params.Domains,
+			WordlistHash: wordlistHash(params.Wordlist),
+			BruteForcing: params.BruteForce,
+			Recursive:    params.Recursive,
+			Created:      time.Now().UTC(),
+		}
+		if err := writeBundle(params.BundleOut, manifest, records, params.SignKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing the result bundle: %v\n", err)
+		}
 	}
 	// Signal that output is complete
 	close(params.Done)
 }
 
+// resultRecord is the stable, versioned shape written to the structured
+// output formats (json, jsonl and csv) for a single AmassRequest.
+type resultRecord struct {
+	Schema    string    `json:"schema"`
+	Name      string    `json:"name"`
+	Domain    string    `json:"domain"`
+	Address   string    `json:"address,omitempty"`
+	Netblock  string    `json:"netblock,omitempty"`
+	ASN       int       `json:"asn,omitempty"`
+	ISP       string    `json:"isp,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func newResultRecord(result *amass.AmassRequest) *resultRecord {
+	var netblock string
+	if result.Netblock.IP != nil {
+		netblock = result.Netblock.String()
+	}
+
+	return &resultRecord{
+		Schema:    outputSchemaVersion,
+		Name:      result.Name,
+		Domain:    result.Domain,
+		Address:   result.Address,
+		Netblock:  netblock,
+		ASN:       result.ASN,
+		ISP:       result.ISP,
+		Tag:       result.Tag,
+		Source:    result.Source,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// ResultEncoder serializes AmassRequest results to one of the supported
+// output formats as they arrive on the results channel.
+type ResultEncoder interface {
+	WriteHeader(w io.Writer) error
+	WriteResult(w io.Writer, result *amass.AmassRequest) error
+	WriteFooter(w io.Writer) error
+}
+
+func newResultEncoder(format string, sources, printIPs bool) (ResultEncoder, error) {
+	switch format {
+	case "", "text":
+		return &textEncoder{sources: sources, printIPs: printIPs}, nil
+	case "json":
+		return &jsonEncoder{}, nil
+	case "jsonl":
+		return &jsonlEncoder{}, nil
+	case "csv":
+		return &csvEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format: %s", format)
+}
+
+type textEncoder struct {
+	sources  bool
+	printIPs bool
+}
+
+func (e *textEncoder) WriteHeader(w io.Writer) error { return nil }
+
+func (e *textEncoder) WriteResult(w io.Writer, result *amass.AmassRequest) error {
+	var line string
+
+	if e.sources {
+		line += fmt.Sprintf("%-14s", "["+result.Source+"] ")
+	}
+	if e.printIPs {
+		line += result.Name + "," + result.Address
+	} else {
+		line += result.Name
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+func (e *textEncoder) WriteFooter(w io.Writer) error { return nil }
+
+// jsonEncoder renders the full result set as a single JSON array.
+type jsonEncoder struct {
+	count int
+}
+
+func (e *jsonEncoder) WriteHeader(w io.Writer) error {
+	_, err := fmt.Fprint(w, "[")
+	return err
+}
+
+func (e *jsonEncoder) WriteResult(w io.Writer, result *amass.AmassRequest) error {
+	if e.count > 0 {
+		if _, err := fmt.Fprint(w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+
+	data, err := json.Marshal(newResultRecord(result))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) WriteFooter(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "]")
+	return err
+}
+
+// jsonlEncoder streams one JSON object per line as results arrive, so a
+// running scan's output file can be tailed.
+type jsonlEncoder struct{}
+
+func (e *jsonlEncoder) WriteHeader(w io.Writer) error { return nil }
+
+func (e *jsonlEncoder) WriteResult(w io.Writer, result *amass.AmassRequest) error {
+	return json.NewEncoder(w).Encode(newResultRecord(result))
+}
+
+func (e *jsonlEncoder) WriteFooter(w io.Writer) error { return nil }
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) WriteHeader(w io.Writer) error {
+	e.w = csv.NewWriter(w)
+	err := e.w.Write([]string{
+		"schema", "name", "domain", "address", "netblock", "asn", "isp", "tag", "source", "timestamp",
+	})
+	e.w.Flush()
+	return err
+}
+
+func (e *csvEncoder) WriteResult(w io.Writer, result *amass.AmassRequest) error {
+	rec := newResultRecord(result)
+
+	err := e.w.Write([]string{
+		rec.Schema,
+		rec.Name,
+		rec.Domain,
+		rec.Address,
+		rec.Netblock,
+		strconv.Itoa(rec.ASN),
+		rec.ISP,
+		rec.Tag,
+		rec.Source,
+		rec.Timestamp.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) WriteFooter(w io.Writer) error { return nil }
+
 func updateData(req *amass.AmassRequest, tags map[string]int, asns map[int]*asnData) {
 	tags[req.Tag]++
 
@@ -199,37 +545,37 @@ func updateData(req *amass.AmassRequest, tags map[string]int, asns map[int]*asnD
 	data.Netblocks[req.Netblock.String()]++
 }
 
-func printSummary(total int, tags map[string]int, asns map[int]*asnData) {
-	fmt.Printf("\n%d names discovered - ", total)
+func printSummary(w io.Writer, total int, tags map[string]int, asns map[int]*asnData) {
+	fmt.Fprintf(w, "\n%d names discovered - ", total)
 
 	// Print the stats using tag information
 	num, length := 1, len(tags)
 	for k, v := range tags {
-		fmt.Printf("%s: %d", k, v)
+		fmt.Fprintf(w, "%s: %d", k, v)
 		if num < length {
-			fmt.Print(", ")
+			fmt.Fprint(w, ", ")
 		}
 	}
-	fmt.Println("")
+	fmt.Fprintln(w, "")
 
 	// Print a line across the terminal
 	for i := 0; i < 8; i++ {
-		fmt.Print("----------")
+		fmt.Fprint(w, "----------")
 	}
-	fmt.Println("")
+	fmt.Fprintln(w, "")
 
 	// Print the ASN and netblock information
 	for asn, data := range asns {
-		fmt.Printf("ASN: %d - %s\n", asn, data.Name)
+		fmt.Fprintf(w, "ASN: %d - %s\n", asn, data.Name)
 
 		for cidr, ips := range data.Netblocks {
 			s := strconv.Itoa(ips)
 
-			fmt.Printf("\t%-18s\t%-3s ", cidr, s)
+			fmt.Fprintf(w, "\t%-18s\t%-3s ", cidr, s)
 			if ips == 1 {
-				fmt.Println("IP address")
+				fmt.Fprintln(w, "IP address")
 			} else {
-				fmt.Println("IP addresses")
+				fmt.Fprintln(w, "IP addresses")
 			}
 		}
 	}
@@ -292,6 +638,139 @@ func getFile(path string) []string {
 	return list
 }
 
+// journalHeader is the first line written to a -journal file, recording
+// enough of the run's configuration to audit or reproduce it later.
+type journalHeader struct {
+	Schema       string        `json:"schema"`
+	AmassVersion string        `json:"amass_version"`
+	Domains      []string      `json:"domains"`
+	WordlistHash string        `json:"wordlist_sha256,omitempty"`
+	BruteForcing bool          `json:"brute_forcing"`
+	Recursive    bool          `json:"recursive"`
+	Frequency    time.Duration `json:"frequency"`
+	Seed         int64         `json:"seed"`
+	Started      time.Time     `json:"started"`
+}
+
+// journalEntry is a single line of a -journal file: either the header
+// record or one discovered result, never both.
+type journalEntry struct {
+	Header *journalHeader `json:"header,omitempty"`
+	Result *resultRecord  `json:"result,omitempty"`
+}
+
+func writeJournalHeader(w io.Writer, domains, wordlist []string, brute, recursive bool, frequency time.Duration, seed int64) {
+	header := &journalHeader{
+		Schema:       journalSchemaVersion,
+		AmassVersion: amassVersion,
+		Domains:      domains,
+		WordlistHash: wordlistHash(wordlist),
+		BruteForcing: brute,
+		Recursive:    recursive,
+		Frequency:    frequency,
+		Seed:         seed,
+		Started:      time.Now().UTC(),
+	}
+	json.NewEncoder(w).Encode(&journalEntry{Header: header})
+}
+
+func writeJournalResult(w io.Writer, result *amass.AmassRequest) {
+	json.NewEncoder(w).Encode(&journalEntry{Result: newResultRecord(result)})
+}
+
+func wordlistHash(words []string) string {
+	h := sha256.New()
+
+	for _, word := range words {
+		h.Write([]byte(word))
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayJournal reads a journal file written by -journal and feeds its
+// recorded results back through the output pipeline without touching
+// the network.
+func replayJournal(path string, results chan *amass.AmassRequest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Error replaying a journal entry: %v\n", err)
+			continue
+		}
+		if entry.Result == nil {
+			continue
+		}
+
+		req, err := entry.Result.toAmassRequest()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error replaying result %q: %v\n", entry.Result.Name, err)
+			continue
+		}
+		results <- req
+	}
+	return scanner.Err()
+}
+
+// loadJournalNames extracts the unique set of names already discovered
+// in a journal file, so an interrupted scan can resume without
+// rediscovering them.
+func loadJournalNames(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	seen := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Result == nil {
+			continue
+		}
+		if _, ok := seen[entry.Result.Name]; !ok {
+			seen[entry.Result.Name] = struct{}{}
+			names = append(names, entry.Result.Name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// toAmassRequest reconstructs the AmassRequest a resultRecord was
+// derived from, for replaying a journal back through manageOutput.
+func (rec *resultRecord) toAmassRequest() (*amass.AmassRequest, error) {
+	var netblock net.IPNet
+
+	if rec.Netblock != "" {
+		_, ipnet, err := net.ParseCIDR(rec.Netblock)
+		if err != nil {
+			return nil, err
+		}
+		netblock = *ipnet
+	}
+
+	return &amass.AmassRequest{
+		Name:     rec.Name,
+		Domain:   rec.Domain,
+		Address:  rec.Address,
+		Netblock: netblock,
+		ASN:      rec.ASN,
+		ISP:      rec.ISP,
+		Tag:      rec.Tag,
+		Source:   rec.Source,
+	}, nil
+}
+
 func freqToDuration(freq int64) time.Duration {
 	if freq > 0 {
 		d := time.Duration(freq)
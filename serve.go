@@ -0,0 +1,258 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caffix/amass/amass"
+)
+
+// scanStatus enumerates the lifecycle of a scan launched through the
+// -serve HTTP API.
+type scanStatus string
+
+const (
+	scanRunning  scanStatus = "running"
+	scanFinished scanStatus = "finished"
+	scanCanceled scanStatus = "canceled"
+)
+
+// scan is a single enumeration launched through the HTTP API. It keeps
+// the results produced so far so /scans/{id} and /scans/{id}/results can
+// be served after the fact, instead of the fire-and-forget model the CLI
+// uses for a single scan.
+type scan struct {
+	ID       string     `json:"id"`
+	Status   scanStatus `json:"status"`
+	Started  time.Time  `json:"started"`
+	Finished time.Time  `json:"finished,omitempty"`
+	Total    int        `json:"total"`
+
+	mu       sync.Mutex
+	records  []*resultRecord
+	canceled bool
+	cancel   chan struct{}
+}
+
+func (s *scan) appendResult(rec *resultRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+	s.Total++
+}
+
+func (s *scan) snapshotResults() []*resultRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*resultRecord, len(s.records))
+	copy(out, s.records)
+	return out
+}
+
+// requestCancel asks the underlying enumeration to stop via the Cancel
+// channel threaded into its AmassConfig. Safe to call more than once.
+func (s *scan) requestCancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canceled {
+		return
+	}
+	s.canceled = true
+	close(s.cancel)
+}
+
+// finish records that the underlying amass.StartAmass call returned,
+// using whether a cancel was requested to tell a user-initiated stop
+// apart from the enumeration completing on its own.
+func (s *scan) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canceled {
+		s.Status = scanCanceled
+	} else {
+		s.Status = scanFinished
+	}
+	s.Finished = time.Now().UTC()
+}
+
+func (s *scan) isDone() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Status != scanRunning
+}
+
+// scanServer tracks every scan launched through the HTTP API. Each scan
+// runs amass.StartAmass against its own AmassConfig and Output channel,
+// but all scans share one limiter ticker so N concurrent scans still
+// collectively honor a single global Frequency rate limit instead of
+// each getting their own full-rate budget.
+type scanServer struct {
+	defaultFreq time.Duration
+	limiter     *time.Ticker
+
+	mu    sync.Mutex
+	scans map[string]*scan
+	next  int
+}
+
+func newScanServer(defaultFreq time.Duration) *scanServer {
+	return &scanServer{
+		defaultFreq: defaultFreq,
+		limiter:     time.NewTicker(defaultFreq),
+		scans:       make(map[string]*scan),
+	}
+}
+
+func (s *scanServer) launch(config *amass.AmassConfig) *scan {
+	if config.Frequency <= 0 {
+		config.Frequency = s.defaultFreq
+	}
+
+	s.mu.Lock()
+	s.next++
+	id := fmt.Sprintf("scan-%d", s.next)
+	s.mu.Unlock()
+
+	sc := &scan{
+		ID:      id,
+		Status:  scanRunning,
+		Started: time.Now().UTC(),
+		cancel:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.scans[id] = sc
+	s.mu.Unlock()
+
+	results := make(chan *amass.AmassRequest, 100)
+	config.Output = results
+	// Cancel propagates into the enumeration itself, and Limiter is
+	// shared across every scan this server is running
+	config.Cancel = sc.cancel
+	config.Limiter = s.limiter.C
+
+	go func() {
+		amass.StartAmass(config)
+		close(results)
+	}()
+
+	go func() {
+		for result := range results {
+			sc.appendResult(newResultRecord(result))
+		}
+		sc.finish()
+	}()
+
+	return sc
+}
+
+func (s *scanServer) get(id string) (*scan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, found := s.scans[id]
+	return sc, found
+}
+
+func (s *scanServer) cancel(id string) bool {
+	sc, found := s.get(id)
+	if !found {
+		return false
+	}
+	sc.requestCancel()
+	return true
+}
+
+// serveAPI starts the -serve HTTP/JSON API and blocks until the listener
+// fails. defaultFreq is used for any scan whose request body does not
+// set its own Frequency.
+func serveAPI(addr string, defaultFreq time.Duration) error {
+	server := newScanServer(defaultFreq)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/scans", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var config amass.AmassConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sc := server.launch(&config)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sc)
+	})
+
+	mux.HandleFunc("/scans/", func(w http.ResponseWriter, r *http.Request) {
+		id, sub := splitScanPath(r.URL.Path)
+
+		sc, found := server.get(id)
+		if !found {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case sub == "" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sc)
+		case sub == "" && r.Method == http.MethodDelete:
+			server.cancel(id)
+			w.WriteHeader(http.StatusNoContent)
+		case sub == "results" && r.Method == http.MethodGet:
+			streamResults(w, sc)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+
+	return http.ListenAndServe(addr, mux)
+}
+
+// streamResults writes the results recorded for a scan so far as NDJSON
+// over a chunked response, then keeps polling and flushing new results
+// until the scan finishes or is canceled.
+func streamResults(w http.ResponseWriter, sc *scan) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, canFlush := w.(http.Flusher)
+
+	sent := 0
+	for {
+		// Read the done state before the snapshot so results appended just
+		// before the scan finished are still written out
+		done := sc.isDone()
+		records := sc.snapshotResults()
+		for ; sent < len(records); sent++ {
+			json.NewEncoder(w).Encode(records[sent])
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+
+		if done {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func splitScanPath(p string) (id, sub string) {
+	p = strings.TrimPrefix(p, "/scans/")
+	parts := strings.SplitN(p, "/", 2)
+
+	id = parts[0]
+	if len(parts) > 1 {
+		sub = parts[1]
+	}
+	return id, sub
+}
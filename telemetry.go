@@ -0,0 +1,124 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/caffix/amass/amass"
+)
+
+// runTUI renders a live, redrawing terminal dashboard from the stats
+// ticks the engine publishes on progress, until the channel is closed.
+func runTUI(progress chan *amass.AmassStats) {
+	for stats := range progress {
+		renderDashboard(os.Stdout, stats)
+	}
+}
+
+// renderDashboard draws one frame of the -tui dashboard: a rate summary,
+// the top sources/tags discovered so far, and brute-force progress.
+func renderDashboard(w io.Writer, stats *amass.AmassStats) {
+	fmt.Fprint(w, "\033[H\033[2J") // Clear and home the terminal
+
+	fmt.Fprintf(w, "DNS queries issued: %d (%d/min, target %d/min)\n",
+		stats.QueriesIssued, stats.QueriesPerMinute, stats.TargetQueriesPerMinute)
+	fmt.Fprintf(w, "Goroutines: %d    Est. memory: %.1f MB\n",
+		stats.ActiveGoroutines, float64(stats.EstMemoryBytes)/(1024*1024))
+
+	if stats.WordlistTotal > 0 {
+		pct := 100 * float64(stats.WordlistPosition) / float64(stats.WordlistTotal)
+		fmt.Fprintf(w, "Brute force wordlist: %d/%d (%.1f%%)\n",
+			stats.WordlistPosition, stats.WordlistTotal, pct)
+	}
+
+	fmt.Fprintln(w, "\nTop sources:")
+	for _, source := range topKeys(stats.NamesBySource, 5) {
+		fmt.Fprintf(w, "  %-20s %d\n", source, stats.NamesBySource[source])
+	}
+
+	fmt.Fprintln(w, "\nTop tags:")
+	for _, tag := range topKeys(stats.NamesByTag, 5) {
+		fmt.Fprintf(w, "  %-20s %d\n", tag, stats.NamesByTag[tag])
+	}
+}
+
+func topKeys(counts map[string]int, limit int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// serveMetrics exposes the latest stats tick as Prometheus text format on
+// /metrics. Once the progress channel is closed the last tick received is
+// still served; the function returns only when the listener fails.
+func serveMetrics(addr string, progress chan *amass.AmassStats) error {
+	var latest atomic.Value // holds *amass.AmassStats
+
+	go func() {
+		for stats := range progress {
+			latest.Store(stats)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if stats, ok := latest.Load().(*amass.AmassStats); ok {
+			writeMetrics(w, stats)
+		}
+	})
+
+	return http.ListenAndServe(addr, mux)
+}
+
+func writeMetrics(w io.Writer, stats *amass.AmassStats) {
+	fmt.Fprintln(w, "# HELP amass_dns_queries_issued_total DNS queries issued so far")
+	fmt.Fprintln(w, "# TYPE amass_dns_queries_issued_total counter")
+	fmt.Fprintf(w, "amass_dns_queries_issued_total %d\n", stats.QueriesIssued)
+
+	fmt.Fprintln(w, "# HELP amass_dns_queries_per_minute DNS queries per minute actually achieved")
+	fmt.Fprintln(w, "# TYPE amass_dns_queries_per_minute gauge")
+	fmt.Fprintf(w, "amass_dns_queries_per_minute %d\n", stats.QueriesPerMinute)
+
+	fmt.Fprintln(w, "# HELP amass_dns_queries_target_per_minute Configured DNS query rate limit")
+	fmt.Fprintln(w, "# TYPE amass_dns_queries_target_per_minute gauge")
+	fmt.Fprintf(w, "amass_dns_queries_target_per_minute %d\n", stats.TargetQueriesPerMinute)
+
+	fmt.Fprintln(w, "# HELP amass_active_goroutines Active goroutines in the enumeration engine")
+	fmt.Fprintln(w, "# TYPE amass_active_goroutines gauge")
+	fmt.Fprintf(w, "amass_active_goroutines %d\n", stats.ActiveGoroutines)
+
+	fmt.Fprintln(w, "# HELP amass_estimated_memory_bytes Estimated memory used by the enumeration engine")
+	fmt.Fprintln(w, "# TYPE amass_estimated_memory_bytes gauge")
+	fmt.Fprintf(w, "amass_estimated_memory_bytes %d\n", stats.EstMemoryBytes)
+
+	fmt.Fprintln(w, "# HELP amass_wordlist_position Current brute-force wordlist position")
+	fmt.Fprintln(w, "# TYPE amass_wordlist_position gauge")
+	fmt.Fprintf(w, "amass_wordlist_position %d\n", stats.WordlistPosition)
+
+	fmt.Fprintln(w, "# HELP amass_wordlist_total Total words in the brute-force wordlist")
+	fmt.Fprintln(w, "# TYPE amass_wordlist_total gauge")
+	fmt.Fprintf(w, "amass_wordlist_total %d\n", stats.WordlistTotal)
+
+	fmt.Fprintln(w, "# HELP amass_names_discovered_total Names discovered, broken down by source and by tag")
+	fmt.Fprintln(w, "# TYPE amass_names_discovered_total counter")
+	for source, count := range stats.NamesBySource {
+		fmt.Fprintf(w, "amass_names_discovered_total{source=%q} %d\n", source, count)
+	}
+	for tag, count := range stats.NamesByTag {
+		fmt.Fprintf(w, "amass_names_discovered_total{tag=%q} %d\n", tag, count)
+	}
+}